@@ -0,0 +1,194 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultMaxSeriesPerMetric is the default per-metric series cap applied
+// when no more specific rule matches.
+const defaultMaxSeriesPerMetric = 10000
+
+// defaultMaxTagsPerSeries is the default cap on the number of tags(labels)
+// a single series may carry.
+const defaultMaxTagsPerSeries = 32
+
+// Limits defines the resource quotas enforced for incoming write traffic.
+// Metrics and TagCardinality map a metric scope to a value; a scope is an
+// exact "ns|name" pair, a bare name(shared across namespaces), or a
+// "*"-wildcard pattern such as "ns|*". Lookups prefer the most specific
+// match. When no scope matches, Metrics falls back to MaxSeriesPerMetric
+// and the other map dimensions report 0, meaning no limit.
+// IngestRatePerSecond and TagValueCardinality accept only the fixed scopes
+// listed on their fields, without wildcard patterns.
+type Limits struct {
+	// MaxSeriesPerMetric is the default per-metric series cap.
+	MaxSeriesPerMetric uint32 `toml:"max-series-per-metric"`
+	// MaxTagsPerSeries is the default cap on the number of tags a series may carry.
+	MaxTagsPerSeries uint32 `toml:"max-tags-per-series"`
+
+	// Metrics overrides MaxSeriesPerMetric per metric scope.
+	Metrics map[string]uint32 `toml:"metrics"`
+	// IngestRatePerSecond caps ingested points/sec per namespace scope("ns" or "*").
+	IngestRatePerSecond map[string]uint32 `toml:"ingest-rate-per-second"`
+	// TagCardinality caps the number of distinct tag combinations per metric scope.
+	TagCardinality map[string]uint32 `toml:"tag-cardinality"`
+	// TagValueCardinality caps the number of distinct values per tag-key scope("ns|name|tagKey", "name|tagKey" or "tagKey").
+	TagValueCardinality map[string]uint32 `toml:"tag-value-cardinality"`
+}
+
+// NewDefaultLimits creates a Limits with default values.
+func NewDefaultLimits() *Limits {
+	return &Limits{
+		MaxSeriesPerMetric:  defaultMaxSeriesPerMetric,
+		MaxTagsPerSeries:    defaultMaxTagsPerSeries,
+		Metrics:             make(map[string]uint32),
+		IngestRatePerSecond: make(map[string]uint32),
+		TagCardinality:      make(map[string]uint32),
+		TagValueCardinality: make(map[string]uint32),
+	}
+}
+
+// TOML returns the TOML representation of Limits.
+func (l *Limits) TOML() string {
+	var buf bytes.Buffer
+	_ = toml.NewEncoder(&buf).Encode(l)
+	return buf.String()
+}
+
+// GetSeriesLimit returns the max series allowed for ns/name.
+func (l *Limits) GetSeriesLimit(ns, name string) uint32 {
+	limit, _ := l.matchMetricRule(l.Metrics, ns, name, l.MaxSeriesPerMetric)
+	return limit
+}
+
+// GetIngestRateLimit returns the max points/sec allowed for ns, and the
+// scope("ns", "*" or "") of the rule that matched, if any.
+func (l *Limits) GetIngestRateLimit(ns string) (limit uint32, matchedRule string) {
+	if v, ok := l.IngestRatePerSecond[ns]; ok {
+		return v, ns
+	}
+	if v, ok := l.IngestRatePerSecond["*"]; ok {
+		return v, "*"
+	}
+	return 0, ""
+}
+
+// GetTagCardinalityLimit returns the max distinct tag combinations allowed
+// for ns/name, and the scope of the rule that matched, if any.
+func (l *Limits) GetTagCardinalityLimit(ns, name string) (limit uint32, matchedRule string) {
+	return l.matchMetricRule(l.TagCardinality, ns, name, 0)
+}
+
+// GetTagValueCardinalityLimit returns the max distinct values allowed for
+// tagKey under ns/name, and the scope of the rule that matched, if any.
+// Lookup tries, from most to least specific: "ns|name|tagKey", "name|tagKey",
+// then the bare tagKey as a cross-metric default.
+func (l *Limits) GetTagValueCardinalityLimit(ns, name, tagKey string) (limit uint32, matchedRule string) {
+	for _, scope := range []string{ns + "|" + name + "|" + tagKey, name + "|" + tagKey, tagKey} {
+		if v, ok := l.TagValueCardinality[scope]; ok {
+			return v, scope
+		}
+	}
+	return 0, ""
+}
+
+// matchMetricRule looks up ns/name in rules, trying from most to least
+// specific: exact "ns|name", then the glob scope(e.g. "ns|*", "*|name")
+// with the most literal(non-wildcard) characters, then the bare
+// "name"(inherited across namespaces), returning def if nothing matches.
+// It also returns the scope of the rule that matched, if any.
+//
+// When several glob scopes match the same key, the one with more literal
+// characters is considered more specific and wins; ties are broken by
+// scope string so the result never depends on map iteration order.
+func (l *Limits) matchMetricRule(rules map[string]uint32, ns, name string, def uint32) (uint32, string) {
+	key := ns + "|" + name
+	if v, ok := rules[key]; ok {
+		return v, key
+	}
+
+	bestScope := ""
+	bestValue := uint32(0)
+	bestSpecificity := -1
+	for scope, v := range rules {
+		if !strings.Contains(scope, "*") || !globMatch(scope, key) {
+			continue
+		}
+		specificity := len(scope) - strings.Count(scope, "*")
+		if specificity > bestSpecificity || (specificity == bestSpecificity && scope < bestScope) {
+			bestSpecificity, bestScope, bestValue = specificity, scope, v
+		}
+	}
+	if bestSpecificity >= 0 {
+		return bestValue, bestScope
+	}
+
+	if v, ok := rules[name]; ok {
+		return v, name
+	}
+	return def, ""
+}
+
+// globMatch reports whether name matches pattern, where pattern may use "*"
+// as a wildcard within either the "ns|metric" segment(path.Match semantics).
+func globMatch(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// limitsCacheKey is the lookup cache key for a resolved series limit.
+type limitsCacheKey struct {
+	ns   string
+	name string
+}
+
+// limitsCache memoizes GetSeriesLimit lookups for the currently active
+// Limits so the write hot path stays O(1) instead of re-matching glob rules
+// on every row.
+type limitsCache struct {
+	mutex sync.RWMutex
+	cache map[limitsCacheKey]uint32
+}
+
+func newLimitsCache() *limitsCache {
+	return &limitsCache{cache: make(map[limitsCacheKey]uint32)}
+}
+
+func (c *limitsCache) get(limits *Limits, ns, name string) uint32 {
+	key := limitsCacheKey{ns: ns, name: name}
+
+	c.mutex.RLock()
+	limit, ok := c.cache[key]
+	c.mutex.RUnlock()
+	if ok {
+		return limit
+	}
+
+	limit = limits.GetSeriesLimit(ns, name)
+	c.mutex.Lock()
+	c.cache[key] = limit
+	c.mutex.Unlock()
+	return limit
+}
@@ -0,0 +1,52 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// dryRunRequest is the DryRunHandler request body.
+type dryRunRequest struct {
+	Limits  *Limits         `json:"limits"`
+	Samples []TrafficSample `json:"samples"`
+}
+
+// DryRunHandler is the admin endpoint that lets an operator validate a
+// proposed Limits document against a sample of recently observed traffic
+// before applying it. It decodes a dryRunRequest body and writes the
+// resulting DryRunResult as JSON; the active policy is never touched. The
+// admin server mounts it under a path such as
+// "/api/v1/storage/limits/dry-run".
+func DryRunHandler(w http.ResponseWriter, r *http.Request) {
+	var req dryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Limits == nil {
+		http.Error(w, "limits is required", http.StatusBadRequest)
+		return
+	}
+
+	result := DryRun(req.Limits, req.Samples)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
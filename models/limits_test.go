@@ -18,7 +18,12 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
@@ -47,3 +52,166 @@ func TestLimits_GetSeriesLimits(t *testing.T) {
 	assert.Equal(t, uint32(100), l.GetSeriesLimit("default-ns", name))
 	assert.Equal(t, l.MaxSeriesPerMetric, l.GetSeriesLimit(ns, "test"))
 }
+
+func TestLimits_GetSeriesLimits_glob(t *testing.T) {
+	l := NewDefaultLimits()
+	l.Metrics["ns|*"] = 20
+	l.Metrics["*|http.*"] = 30
+
+	assert.Equal(t, uint32(20), l.GetSeriesLimit("ns", "anything"))
+	assert.Equal(t, uint32(30), l.GetSeriesLimit("other-ns", "http.requests"))
+	// a more specific exact rule still wins over a glob
+	l.Metrics["ns|name"] = 10
+	assert.Equal(t, uint32(10), l.GetSeriesLimit("ns", "name"))
+}
+
+func TestLimits_GetSeriesLimits_globPrecedenceIsDeterministic(t *testing.T) {
+	l := NewDefaultLimits()
+	// both globs match "ns|name"; "*|name" has more literal characters than
+	// "ns|*" so it wins, consistently, regardless of map iteration order
+	l.Metrics["ns|*"] = 20
+	l.Metrics["*|name"] = 5
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, uint32(5), l.GetSeriesLimit("ns", "name"))
+	}
+}
+
+func TestLimits_GetTagValueCardinalityLimit(t *testing.T) {
+	l := NewDefaultLimits()
+	l.TagValueCardinality["host"] = 1000
+	l.TagValueCardinality["ns|name|region"] = 5
+
+	limit, rule := l.GetTagValueCardinalityLimit("ns", "name", "host")
+	assert.Equal(t, uint32(1000), limit)
+	assert.Equal(t, "host", rule)
+
+	limit, rule = l.GetTagValueCardinalityLimit("ns", "name", "region")
+	assert.Equal(t, uint32(5), limit)
+	assert.Equal(t, "ns|name|region", rule)
+
+	limit, rule = l.GetTagValueCardinalityLimit("ns", "name", "unknown")
+	assert.Equal(t, uint32(0), limit)
+	assert.Empty(t, rule)
+}
+
+type mockLimitsSource struct {
+	limits *Limits
+}
+
+func (s *mockLimitsSource) Load() (*Limits, error)                   { return s.limits, nil }
+func (s *mockLimitsSource) Watch(_ func(*Limits), _ <-chan struct{}) {}
+
+func TestLimitsWatcher_hotReload(t *testing.T) {
+	initial := NewDefaultLimits()
+	initial.Metrics["ns|name"] = 10
+	initial.IngestRatePerSecond["ns"] = 100
+	source := &mockLimitsSource{limits: initial}
+
+	var exceeded []LimitExceededEvent
+	w, err := NewLimitsWatcher(source, func(e LimitExceededEvent) {
+		exceeded = append(exceeded, e)
+	})
+	assert.NoError(t, err)
+	defer w.Close()
+
+	assert.Equal(t, uint32(10), w.GetSeriesLimit("ns", "name"))
+	assert.True(t, w.CheckSeriesLimit("ns", "name", 5))
+	assert.False(t, w.CheckSeriesLimit("ns", "name", 20))
+	assert.Len(t, exceeded, 1)
+	assert.Equal(t, "ns|name", exceeded[0].Rule)
+
+	assert.True(t, w.CheckIngestRate("ns", 50))
+	assert.False(t, w.CheckIngestRate("ns", 200))
+	assert.Len(t, exceeded, 2)
+	assert.Equal(t, LimitKindIngestRate, exceeded[1].Kind)
+	assert.Equal(t, "ns", exceeded[1].Rule)
+
+	// swapping the active policy invalidates the lookup cache
+	reloaded := NewDefaultLimits()
+	reloaded.Metrics["ns|name"] = 50
+	w.swap(reloaded)
+	assert.Equal(t, uint32(50), w.GetSeriesLimit("ns", "name"))
+}
+
+func TestDryRun(t *testing.T) {
+	proposed := NewDefaultLimits()
+	proposed.Metrics["ns|name"] = 10
+	proposed.MaxTagsPerSeries = 5
+	proposed.TagCardinality["ns|name"] = 100
+	proposed.IngestRatePerSecond["ns"] = 1000
+	proposed.TagValueCardinality["ns|name|host"] = 50
+
+	result := DryRun(proposed, []TrafficSample{
+		{NS: "ns", Metric: "name", SeriesCount: 20, TagCount: 3, TagCardinality: 200},
+		{NS: "ns", Metric: "other", SeriesCount: 1, TagCount: 10},
+		{NS: "ns", Metric: "name", IngestRate: 2000},
+		{NS: "ns", Metric: "name", TagKey: "host", TagValueCardinality: 75},
+	})
+
+	assert.Len(t, result.Exceeded, 5)
+	kinds := make([]LimitKind, len(result.Exceeded))
+	for i, e := range result.Exceeded {
+		kinds[i] = e.Kind
+	}
+	assert.Contains(t, kinds, LimitKindTagCardinality)
+	assert.Contains(t, kinds, LimitKindIngestRate)
+	assert.Contains(t, kinds, LimitKindTagValueCount)
+}
+
+func TestDryRunHandler(t *testing.T) {
+	body, err := json.Marshal(dryRunRequest{
+		Limits: &Limits{MaxSeriesPerMetric: 10, Metrics: map[string]uint32{}},
+		Samples: []TrafficSample{
+			{NS: "ns", Metric: "name", SeriesCount: 20},
+		},
+	})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/storage/limits/dry-run", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	DryRunHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var result DryRunResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Len(t, result.Exceeded, 1)
+}
+
+func TestDryRunHandler_missingLimits(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/storage/limits/dry-run", bytes.NewReader([]byte("{}")))
+	rec := httptest.NewRecorder()
+
+	DryRunHandler(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestFileLimitsSource_Watch_logsAndRecoversFromBadFile(t *testing.T) {
+	good := NewDefaultLimits().TOML()
+	attempt := 0
+	reads := []string{"not valid toml[[[", good}
+
+	source := NewFileLimitsSource("limits.toml", time.Millisecond)
+	source.readFile = func(path string) (string, error) {
+		data := reads[attempt]
+		if attempt < len(reads)-1 {
+			attempt++
+		}
+		return data, nil
+	}
+
+	changed := make(chan *Limits, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go source.Watch(func(l *Limits) { changed <- l }, stop)
+
+	select {
+	case l := <-changed:
+		assert.Equal(t, NewDefaultLimits(), l)
+	case <-time.After(time.Second):
+		t.Fatal("expected Watch to recover and report the valid document")
+	}
+}
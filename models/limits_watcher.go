@@ -0,0 +1,311 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/lindb/lindb/internal/linmetric"
+	"github.com/lindb/lindb/pkg/logger"
+)
+
+// limitsScope/limitExceededCounter report how often each quota dimension
+// rejects traffic, tagged by the dimension that fired.
+var (
+	limitsScope          = linmetric.NewScope("lindb.limits")
+	limitExceededCounter = limitsScope.NewCounterVec("limit_exceeded_total", "kind")
+)
+
+// LimitKind identifies which quota dimension a LimitExceededEvent reports on.
+type LimitKind string
+
+// LimitKind values, one per quota dimension Limits enforces.
+const (
+	LimitKindSeriesPerMetric LimitKind = "series-per-metric"
+	LimitKindIngestRate      LimitKind = "ingest-rate"
+	LimitKindTagCardinality  LimitKind = "tag-cardinality"
+	LimitKindTagValueCount   LimitKind = "tag-value-cardinality"
+	LimitKindTagsPerSeries   LimitKind = "tags-per-series"
+)
+
+// LimitExceededEvent is emitted whenever traffic is rejected by a Limits
+// rule, so operators can trace which policy fired.
+type LimitExceededEvent struct {
+	Kind   LimitKind
+	NS     string
+	Metric string
+	Rule   string // the scope key of the rule that fired, e.g. "ns|*"
+	Limit  uint32
+	Actual uint32
+}
+
+// LimitsSource loads the current Limits document, from disk or from the
+// meta cluster(an etcd-style watch), depending on the implementation.
+type LimitsSource interface {
+	// Load returns the current Limits document.
+	Load() (*Limits, error)
+	// Watch invokes onChange every time the source's Limits document
+	// changes, until stop is closed.
+	Watch(onChange func(*Limits), stop <-chan struct{})
+}
+
+// LimitsWatcher holds the Limits policy currently in effect, refreshing it
+// from a LimitsSource in the background and caching per-(ns, name) lookups
+// against whichever policy is active.
+type LimitsWatcher struct {
+	source LimitsSource
+	active atomic.Pointer[Limits]
+	cache  atomic.Pointer[limitsCache]
+	stop   chan struct{}
+
+	onExceeded func(event LimitExceededEvent)
+}
+
+// NewLimitsWatcher creates a LimitsWatcher, performing an initial load from
+// source and starting a background watch for subsequent changes.
+func NewLimitsWatcher(source LimitsSource, onExceeded func(event LimitExceededEvent)) (*LimitsWatcher, error) {
+	limits, err := source.Load()
+	if err != nil {
+		return nil, err
+	}
+	w := &LimitsWatcher{
+		source:     source,
+		stop:       make(chan struct{}),
+		onExceeded: onExceeded,
+	}
+	w.swap(limits)
+	go source.Watch(w.swap, w.stop)
+	return w, nil
+}
+
+// swap atomically installs limits as the active policy and discards the
+// now-stale lookup cache.
+func (w *LimitsWatcher) swap(limits *Limits) {
+	w.active.Store(limits)
+	w.cache.Store(newLimitsCache())
+}
+
+// Close stops the background watch.
+func (w *LimitsWatcher) Close() {
+	close(w.stop)
+}
+
+// Limits returns the currently active Limits document.
+func (w *LimitsWatcher) Limits() *Limits {
+	return w.active.Load()
+}
+
+// GetSeriesLimit returns the max series allowed for ns/name under the
+// currently active policy, served from the(ns, name) lookup cache.
+func (w *LimitsWatcher) GetSeriesLimit(ns, name string) uint32 {
+	return w.cache.Load().get(w.active.Load(), ns, name)
+}
+
+// CheckSeriesLimit reports whether actual is within the series cap for
+// ns/name; when it exceeds the cap, it emits a LimitExceededEvent naming
+// the matched rule and returns false.
+func (w *LimitsWatcher) CheckSeriesLimit(ns, name string, actual uint32) bool {
+	limit := w.GetSeriesLimit(ns, name)
+	if actual <= limit {
+		return true
+	}
+	_, rule := w.active.Load().matchMetricRule(w.active.Load().Metrics, ns, name, limit)
+	w.emit(LimitExceededEvent{
+		Kind: LimitKindSeriesPerMetric, NS: ns, Metric: name,
+		Rule: rule, Limit: limit, Actual: actual,
+	})
+	return false
+}
+
+// CheckIngestRate reports whether actual(points/sec) is within the ingest
+// rate cap for ns(always true when no cap applies); when it exceeds the
+// cap, it emits a LimitExceededEvent naming the matched rule and returns
+// false.
+func (w *LimitsWatcher) CheckIngestRate(ns string, actual uint32) bool {
+	limits := w.active.Load()
+	limit, rule := limits.GetIngestRateLimit(ns)
+	if limit == 0 || actual <= limit {
+		return true
+	}
+	w.emit(LimitExceededEvent{
+		Kind: LimitKindIngestRate, NS: ns,
+		Rule: rule, Limit: limit, Actual: actual,
+	})
+	return false
+}
+
+func (w *LimitsWatcher) emit(event LimitExceededEvent) {
+	limitExceededCounter.WithTagValues(string(event.Kind)).Incr()
+	if w.onExceeded != nil {
+		w.onExceeded(event)
+	}
+}
+
+// DryRunResult is the outcome of validating a proposed Limits document
+// against a sample of recently observed traffic, without applying it.
+type DryRunResult struct {
+	Exceeded []LimitExceededEvent
+}
+
+// TrafficSample is one observed data point used to dry-run a proposed
+// Limits document: the actual series/tag counts seen for NS/Metric.
+type TrafficSample struct {
+	NS             string
+	Metric         string
+	SeriesCount    uint32
+	IngestRate     uint32 // observed points/sec for NS, checked against IngestRatePerSecond
+	TagCount       uint32
+	TagCardinality uint32
+
+	// TagKey and TagValueCardinality together observe the distinct value
+	// count for one tag key under NS/Metric, checked against
+	// TagValueCardinality. Left zero-valued, the tag-value-cardinality
+	// dimension is skipped for this sample.
+	TagKey              string
+	TagValueCardinality uint32
+}
+
+// DryRun evaluates proposed against samples and reports every rule that
+// would have fired, without swapping the active policy. This backs an
+// admin HTTP endpoint that lets operators validate a new Limits document
+// against recent traffic before applying it.
+func DryRun(proposed *Limits, samples []TrafficSample) DryRunResult {
+	var result DryRunResult
+	for _, s := range samples {
+		if limit := proposed.GetSeriesLimit(s.NS, s.Metric); s.SeriesCount > limit {
+			_, rule := proposed.matchMetricRule(proposed.Metrics, s.NS, s.Metric, limit)
+			result.Exceeded = append(result.Exceeded, LimitExceededEvent{
+				Kind: LimitKindSeriesPerMetric, NS: s.NS, Metric: s.Metric,
+				Rule: rule, Limit: limit, Actual: s.SeriesCount,
+			})
+		}
+		if s.TagCount > proposed.MaxTagsPerSeries {
+			result.Exceeded = append(result.Exceeded, LimitExceededEvent{
+				Kind: LimitKindTagsPerSeries, NS: s.NS, Metric: s.Metric,
+				Limit: proposed.MaxTagsPerSeries, Actual: s.TagCount,
+			})
+		}
+		if limit, rule := proposed.GetTagCardinalityLimit(s.NS, s.Metric); limit > 0 && s.TagCardinality > limit {
+			result.Exceeded = append(result.Exceeded, LimitExceededEvent{
+				Kind: LimitKindTagCardinality, NS: s.NS, Metric: s.Metric,
+				Rule: rule, Limit: limit, Actual: s.TagCardinality,
+			})
+		}
+		if limit, rule := proposed.GetIngestRateLimit(s.NS); limit > 0 && s.IngestRate > limit {
+			result.Exceeded = append(result.Exceeded, LimitExceededEvent{
+				Kind: LimitKindIngestRate, NS: s.NS,
+				Rule: rule, Limit: limit, Actual: s.IngestRate,
+			})
+		}
+		if s.TagKey != "" {
+			if limit, rule := proposed.GetTagValueCardinalityLimit(s.NS, s.Metric, s.TagKey); limit > 0 && s.TagValueCardinality > limit {
+				result.Exceeded = append(result.Exceeded, LimitExceededEvent{
+					Kind: LimitKindTagValueCount, NS: s.NS, Metric: s.Metric,
+					Rule: rule, Limit: limit, Actual: s.TagValueCardinality,
+				})
+			}
+		}
+	}
+	return result
+}
+
+// decodeLimitsTOML decodes a TOML document into a Limits document.
+func decodeLimitsTOML(data string) (*Limits, error) {
+	cfg := &Limits{}
+	if _, err := toml.Decode(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FileLimitsSource is a LimitsSource backed by a TOML file on local disk,
+// polled periodically for changes.
+type FileLimitsSource struct {
+	// Path is the TOML file's location on disk.
+	Path string
+	// PollInterval controls how often Watch re-reads Path looking for changes.
+	PollInterval time.Duration
+
+	// readFile abstracts file reads so tests can stub disk access.
+	readFile func(path string) (string, error)
+
+	logger logger.Logger
+}
+
+// NewFileLimitsSource creates a FileLimitsSource reading path, polled every interval.
+func NewFileLimitsSource(path string, interval time.Duration) *FileLimitsSource {
+	return &FileLimitsSource{
+		Path:         path,
+		PollInterval: interval,
+		readFile:     readFileString,
+		logger:       logger.GetLogger("Models", "LimitsSource"),
+	}
+}
+
+// Load reads and decodes the Limits document at s.Path.
+func (s *FileLimitsSource) Load() (*Limits, error) {
+	data, err := s.readFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLimitsTOML(data)
+}
+
+// Watch polls s.Path every s.PollInterval, invoking onChange whenever the
+// decoded document differs from the last one observed, until stop is closed.
+func (s *FileLimitsSource) Watch(onChange func(*Limits), stop <-chan struct{}) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	var lastRaw string
+	for {
+		select {
+		case <-ticker.C:
+			data, err := s.readFile(s.Path)
+			if err != nil {
+				s.logger.Error("read limits file", logger.String("path", s.Path), logger.Error(err))
+				continue
+			}
+			if data == lastRaw {
+				continue
+			}
+			limits, err := decodeLimitsTOML(data)
+			if err != nil {
+				s.logger.Error("decode limits file", logger.String("path", s.Path), logger.Error(err))
+				continue
+			}
+			lastRaw = data
+			onChange(limits)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// readFileString reads path's contents as a string.
+func readFileString(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -76,6 +76,58 @@ func (stage *baseStage) execute(node PlanNode) error {
 	return nil
 }
 
+// ExecuteStream executes the plan node like Execute, but delivers partial
+// results via onPartial as they become available instead of only invoking
+// completeHandle once the whole sub-tree finishes. Plan nodes that
+// implement StreamingPlanNode stream their own results; others fall back
+// to a single terminal Execute. Chunks are funneled through a bounded
+// channel so a slow consumer applies backpressure on the producer rather
+// than letting partial results buffer unbounded.
+func (stage *baseStage) ExecuteStream(node PlanNode, onPartial func(chunk ResultChunk), completeHandle func(), errHandle func(err error)) {
+	send, done := newBoundedChunkSink(streamChunkBufferSize, onPartial)
+	execFn := func() {
+		// execute sub plan tree for current stage, streaming partial results
+		err := stage.executeStream(node, send)
+		done()
+		if err != nil {
+			errHandle(err)
+		} else {
+			completeHandle()
+		}
+	}
+	if stage.execPool == nil || stage.ctx == nil {
+		execFn()
+	} else {
+		stage.execPool.Submit(stage.ctx, concurrent.NewTask(func() {
+			execFn()
+		}, errHandle))
+	}
+}
+
+// executeStream streams node's results via send, then recurses into its
+// children in the same traversal order as execute.
+func (stage *baseStage) executeStream(node PlanNode, send func(chunk ResultChunk)) error {
+	if node == nil {
+		return nil
+	}
+
+	if streaming, ok := node.(StreamingPlanNode); ok {
+		if err := streaming.ExecuteStream(send); err != nil {
+			return err
+		}
+	} else if err := node.Execute(); err != nil {
+		return err
+	}
+
+	children := node.Children()
+	for idx := range children {
+		if err := stage.executeStream(children[idx], send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Complete completes current stage.
 func (stage *baseStage) Complete() {
 }
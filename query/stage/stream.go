@@ -0,0 +1,66 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stage
+
+// streamChunkBufferSize bounds the number of in-flight ResultChunk values
+// buffered between a streaming plan node and its consumer.
+const streamChunkBufferSize = 64
+
+// ResultChunk is one partial result of a streaming query, delivered to the
+// caller as soon as it becomes available instead of waiting for the whole
+// plan tree to complete.
+type ResultChunk struct {
+	// SeriesID identifies the time series this chunk's fields belong to.
+	SeriesID uint32
+	// Fields holds the field name -> value pairs computed for this chunk.
+	Fields map[string]float64
+	// Completed marks the final chunk produced by the node that emitted it.
+	Completed bool
+}
+
+// StreamingPlanNode is the optional capability a PlanNode implements when
+// it can emit its results in chunks as it computes them, instead of only
+// a single value once it's done. baseStage detects it via a type
+// assertion, so existing PlanNode implementations don't need to change.
+type StreamingPlanNode interface {
+	PlanNode
+
+	// ExecuteStream executes the node, invoking onPartial for every chunk
+	// of results it produces as they become available.
+	ExecuteStream(onPartial func(chunk ResultChunk)) error
+}
+
+// newBoundedChunkSink returns a send func that forwards chunks to onPartial
+// through a channel of capacity, and a done func that must be called once
+// the producer is finished. Bounding the channel means a producer that
+// generates chunks faster than onPartial drains them blocks on send,
+// applying backpressure instead of buffering unbounded partial results.
+func newBoundedChunkSink(capacity int, onPartial func(chunk ResultChunk)) (send func(chunk ResultChunk), done func()) {
+	ch := make(chan ResultChunk, capacity)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for chunk := range ch {
+			onPartial(chunk)
+		}
+	}()
+	return func(chunk ResultChunk) { ch <- chunk }, func() {
+		close(ch)
+		<-finished
+	}
+}
@@ -0,0 +1,148 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package stage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePlanNode is a non-streaming PlanNode test double.
+type fakePlanNode struct {
+	executed bool
+	err      error
+	children []PlanNode
+}
+
+func (n *fakePlanNode) Execute() error      { n.executed = true; return n.err }
+func (n *fakePlanNode) Children() []PlanNode { return n.children }
+
+// fakeStreamingPlanNode is a StreamingPlanNode test double that emits its
+// own chunks instead of going through Execute.
+type fakeStreamingPlanNode struct {
+	fakePlanNode
+	chunks []ResultChunk
+}
+
+func (n *fakeStreamingPlanNode) ExecuteStream(onPartial func(chunk ResultChunk)) error {
+	for _, c := range n.chunks {
+		onPartial(c)
+	}
+	return n.err
+}
+
+func TestBaseStage_ExecuteStream_streamingNode(t *testing.T) {
+	root := &fakeStreamingPlanNode{chunks: []ResultChunk{{SeriesID: 1}, {SeriesID: 2, Completed: true}}}
+	stage := &baseStage{}
+
+	var mutex sync.Mutex
+	var received []ResultChunk
+	complete := make(chan struct{})
+
+	stage.ExecuteStream(root, func(chunk ResultChunk) {
+		mutex.Lock()
+		received = append(received, chunk)
+		mutex.Unlock()
+	}, func() {
+		close(complete)
+	}, func(err error) {
+		t.Fatalf("unexpected error: %v", err)
+	})
+
+	<-complete
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.False(t, root.executed) // streaming nodes stream instead of calling Execute
+	assert.Equal(t, []ResultChunk{{SeriesID: 1}, {SeriesID: 2, Completed: true}}, received)
+}
+
+func TestBaseStage_ExecuteStream_fallsBackToExecute(t *testing.T) {
+	root := &fakePlanNode{}
+	stage := &baseStage{}
+
+	complete := make(chan struct{})
+	stage.ExecuteStream(root, func(chunk ResultChunk) {
+		t.Fatal("non-streaming node should not emit chunks")
+	}, func() {
+		close(complete)
+	}, func(err error) {
+		t.Fatalf("unexpected error: %v", err)
+	})
+
+	<-complete
+	assert.True(t, root.executed)
+}
+
+func TestBaseStage_ExecuteStream_childError(t *testing.T) {
+	child := &fakePlanNode{err: errors.New("boom")}
+	root := &fakePlanNode{children: []PlanNode{child}}
+	stage := &baseStage{}
+
+	errCh := make(chan error, 1)
+	stage.ExecuteStream(root, func(chunk ResultChunk) {}, func() {
+		t.Fatal("completeHandle must not be called when a child errors")
+	}, func(err error) {
+		errCh <- err
+	})
+
+	assert.EqualError(t, <-errCh, "boom")
+}
+
+func TestNewBoundedChunkSink_backpressure(t *testing.T) {
+	entered := make(chan struct{}, 3)
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	var drainedOrder []uint32
+
+	send, done := newBoundedChunkSink(1, func(chunk ResultChunk) {
+		entered <- struct{}{}
+		<-release // hold the consumer until the test allows it through
+		mutex.Lock()
+		drainedOrder = append(drainedOrder, chunk.SeriesID)
+		mutex.Unlock()
+	})
+
+	send(ResultChunk{SeriesID: 1})
+	<-entered // consumer is now blocked inside onPartial(chunk1); its buffer slot is free again
+
+	send(ResultChunk{SeriesID: 2}) // fills the one free buffer slot, does not block
+
+	blocked := make(chan struct{})
+	go func() {
+		send(ResultChunk{SeriesID: 3}) // buffer full and consumer busy: must block
+		close(blocked)
+	}()
+
+	select {
+	case <-blocked:
+		t.Fatal("send should block while the bounded channel is full and the consumer is busy")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-blocked
+	done()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []uint32{1, 2, 3}, drainedOrder)
+}
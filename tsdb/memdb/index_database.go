@@ -0,0 +1,262 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package memdb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lindb/lindb/index"
+	"github.com/lindb/lindb/pkg/logger"
+	"github.com/lindb/lindb/series/metric"
+)
+
+// TimeSeriesIndex represents the in-memory index(tags/fields) for all time
+// series tracked under one metric.
+type TimeSeriesIndex interface {
+}
+
+// FlushEvent notifies the index database to flush its backing metric index
+// database, invoking Callback with the flush result once done.
+type FlushEvent struct {
+	Callback func(err error)
+}
+
+// MetadataDatabase translates a metric's namespace/name into a metric id,
+// backed by the metric meta database.
+type MetadataDatabase interface {
+	// GenMetricID returns the metric id for ns/name, generating a new one
+	// via the backend metric meta database if it doesn't exist yet.
+	GenMetricID(ns, name []byte) (metric.ID, error)
+}
+
+// metadataDatabase implements MetadataDatabase.
+type metadataDatabase struct {
+	metaDB index.MetricMetaDatabase
+}
+
+// NewMetadataDatabase creates a MetadataDatabase instance.
+func NewMetadataDatabase(metaDB index.MetricMetaDatabase) MetadataDatabase {
+	return &metadataDatabase{metaDB: metaDB}
+}
+
+// GenMetricID returns the metric id for ns/name.
+func (db *metadataDatabase) GenMetricID(ns, name []byte) (metric.ID, error) {
+	return db.metaDB.GenMetricID(ns, name)
+}
+
+// IndexDatabase represents the index database of the memory database, it
+// maintains the mapping between incoming time series and their index.
+type IndexDatabase interface {
+	// GetOrCreateTimeSeriesIndex returns the time series index for row's
+	// metric, creating one if it doesn't exist yet.
+	GetOrCreateTimeSeriesIndex(row metric.StorageRow) TimeSeriesIndex
+	// GetTimeSeriesIndex returns the time series index registered under
+	// metricID, false if not found.
+	GetTimeSeriesIndex(metricID metric.ID) (TimeSeriesIndex, bool)
+	// ClearTimeRange clears the index state kept for metricID.
+	ClearTimeRange(metricID metric.ID)
+	// SetTopNFlusher registers the flusher used to persist TopN snapshots on flush.
+	SetTopNFlusher(flusher TopNFlusher)
+	// QueryTopN returns the k largest series for metricID/measure/window
+	// ranked by their estimated value, descending, along with their error bound.
+	QueryTopN(metricID metric.ID, window time.Duration, measure string, k int) []TopNResult
+	// Notify submits a background event(e.g. *FlushEvent) for processing.
+	Notify(event interface{})
+	// Close closes the index database and releases its resources.
+	Close()
+}
+
+// indexDatabase implements IndexDatabase.
+type indexDatabase struct {
+	metaDB  MetadataDatabase
+	indexDB index.MetricIndexDatabase
+
+	topN        *TopNIndex
+	topNFlusher TopNFlusher
+
+	mutex    sync.RWMutex
+	byHash   map[uint64]TimeSeriesIndex    // time series index keyed by metric name hash, before id resolved
+	byID     map[metric.ID]TimeSeriesIndex // time series index keyed by metric id, after id resolved
+	hashByID map[metric.ID]uint64          // name hash the metric id was resolved from, so ClearTimeRange can evict both maps
+
+	events chan interface{}
+	done   chan struct{}
+
+	logger logger.Logger
+}
+
+// NewIndexDatabase creates an IndexDatabase instance.
+func NewIndexDatabase(metaDB MetadataDatabase, indexDB index.MetricIndexDatabase) IndexDatabase {
+	db := &indexDatabase{
+		metaDB:   metaDB,
+		indexDB:  indexDB,
+		topN:     NewTopNIndex(),
+		byHash:   make(map[uint64]TimeSeriesIndex),
+		byID:     make(map[metric.ID]TimeSeriesIndex),
+		hashByID: make(map[metric.ID]uint64),
+		events:   make(chan interface{}, 16),
+		done:     make(chan struct{}),
+		logger:   logger.GetLogger("MemDB", "IndexDatabase"),
+	}
+	go db.handleEvent()
+	return db
+}
+
+// SetTopNFlusher registers the flusher used to persist TopN snapshots on flush.
+func (db *indexDatabase) SetTopNFlusher(flusher TopNFlusher) {
+	db.topNFlusher = flusher
+}
+
+// QueryTopN returns the k largest series for metricID/measure/window.
+func (db *indexDatabase) QueryTopN(metricID metric.ID, window time.Duration, measure string, k int) []TopNResult {
+	return db.topN.QueryTopN(metricID, window, measure, k)
+}
+
+// GetOrCreateTimeSeriesIndex returns the time series index for row's metric.
+func (db *indexDatabase) GetOrCreateTimeSeriesIndex(row metric.StorageRow) TimeSeriesIndex {
+	return db.getOrCreateTimeSeriesIndex(row.NameHash())
+}
+
+// getOrCreateTimeSeriesIndex returns the time series index keyed by nameHash.
+func (db *indexDatabase) getOrCreateTimeSeriesIndex(nameHash uint64) TimeSeriesIndex {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	tsIndex, ok := db.byHash[nameHash]
+	if !ok {
+		tsIndex = newTimeSeriesIndex()
+		db.byHash[nameHash] = tsIndex
+	}
+	return tsIndex
+}
+
+// GetTimeSeriesIndex returns the time series index registered under metricID.
+func (db *indexDatabase) GetTimeSeriesIndex(metricID metric.ID) (TimeSeriesIndex, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	tsIndex, ok := db.byID[metricID]
+	return tsIndex, ok
+}
+
+// ClearTimeRange clears the index state kept for metricID, including its
+// entry in byHash, which is otherwise only ever added to.
+func (db *indexDatabase) ClearTimeRange(metricID metric.ID) {
+	db.mutex.Lock()
+	delete(db.byID, metricID)
+	if nameHash, ok := db.hashByID[metricID]; ok {
+		delete(db.byHash, nameHash)
+		delete(db.hashByID, metricID)
+	}
+	db.mutex.Unlock()
+
+	// expire the TopN sketches along with the rest of the metric's index state
+	db.topN.ClearTimeRange(metricID)
+}
+
+// Notify submits a background event for async processing.
+func (db *indexDatabase) Notify(event interface{}) {
+	db.events <- event
+}
+
+// Close closes the index database.
+func (db *indexDatabase) Close() {
+	close(db.done)
+}
+
+// handleEvent drains background events(flush/write) until Close is called.
+func (db *indexDatabase) handleEvent() {
+	for {
+		select {
+		case event := <-db.events:
+			switch e := event.(type) {
+			case *FlushEvent:
+				db.flush(e)
+			case metric.StorageRow:
+				db.handleRow(e)
+			}
+		case <-db.done:
+			return
+		}
+	}
+}
+
+// flush flushes the backing metric index database, then hands each
+// indexed metric's current TopN snapshot to the registered TopNFlusher.
+func (db *indexDatabase) flush(event *FlushEvent) {
+	if db.indexDB == nil {
+		db.flushTopN()
+		event.Callback(nil)
+		return
+	}
+	db.indexDB.PrepareFlush()
+	err := db.indexDB.Flush()
+	db.flushTopN()
+	event.Callback(err)
+}
+
+// flushTopN snapshots the current top-K of every tracked metric and hands
+// it to the registered TopNFlusher, if any.
+func (db *indexDatabase) flushTopN() {
+	if db.topNFlusher == nil {
+		return
+	}
+	db.mutex.RLock()
+	metricIDs := make([]metric.ID, 0, len(db.byID))
+	for metricID := range db.byID {
+		metricIDs = append(metricIDs, metricID)
+	}
+	db.mutex.RUnlock()
+
+	for _, metricID := range metricIDs {
+		snapshot := db.topN.Snapshot(metricID)
+		if len(snapshot) == 0 {
+			continue
+		}
+		if err := db.topNFlusher.FlushTopN(metricID, snapshot); err != nil {
+			db.logger.Error("flush topN snapshot", logger.Error(err))
+		}
+	}
+}
+
+// handleRow resolves row's metric id, registers its time series index under
+// that id, and feeds the row's measures into the TopN sketches.
+func (db *indexDatabase) handleRow(row metric.StorageRow) {
+	metricID, err := db.metaDB.GenMetricID(row.Namespace(), row.Name())
+	if err != nil {
+		db.logger.Error("generate metric id", logger.Error(err))
+		return
+	}
+
+	nameHash := row.NameHash()
+	tsIndex := db.getOrCreateTimeSeriesIndex(nameHash)
+	db.mutex.Lock()
+	db.byID[metricID] = tsIndex
+	db.hashByID[metricID] = nameHash
+	db.mutex.Unlock()
+
+	groupByKey := row.GroupByTagsKey()
+	for _, f := range row.SimpleFields() {
+		db.topN.Observe(metricID, groupByKey, f.Name(), f.Value())
+	}
+}
+
+// newTimeSeriesIndex creates a new, empty TimeSeriesIndex.
+func newTimeSeriesIndex() TimeSeriesIndex {
+	return struct{}{}
+}
@@ -20,6 +20,7 @@ package memdb
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	protoMetricsV1 "github.com/lindb/common/proto/gen/v1/linmetrics"
 	"github.com/stretchr/testify/assert"
@@ -101,4 +102,89 @@ func TestIndexDatabase_handleRow(t *testing.T) {
 
 	metaDB.EXPECT().GenMetricID([]byte("ns"), []byte("test1")).Return(metric.ID(0), fmt.Errorf("err"))
 	indexDB.(*indexDatabase).handleRow(row)
-}
\ No newline at end of file
+}
+
+type mockTopNFlusher struct {
+	flushed map[metric.ID]map[string][]TopNResult
+}
+
+func (f *mockTopNFlusher) FlushTopN(metricID metric.ID, snapshot map[string][]TopNResult) error {
+	if f.flushed == nil {
+		f.flushed = make(map[metric.ID]map[string][]TopNResult)
+	}
+	f.flushed[metricID] = snapshot
+	return nil
+}
+
+func TestIndexDatabase_SetTopNFlusher_and_QueryTopN(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metaDB := index.NewMockMetricMetaDatabase(ctrl)
+	memMetaDB := NewMetadataDatabase(metaDB)
+
+	// IndexDatabase is the interface NewIndexDatabase returns; both
+	// SetTopNFlusher and QueryTopN must be reachable through it, with no
+	// need to assert down to *indexDatabase.
+	var idx IndexDatabase = NewIndexDatabase(memMetaDB, nil)
+	defer idx.Close()
+
+	flusher := &mockTopNFlusher{}
+	idx.SetTopNFlusher(flusher)
+
+	m := &protoMetricsV1.Metric{
+		Name:      "test1",
+		Namespace: "ns",
+		Tags:      []*protoMetricsV1.KeyValue{{Key: "key1", Value: "value1"}},
+		SimpleFields: []*protoMetricsV1.SimpleField{
+			{Name: "f1", Type: protoMetricsV1.SimpleFieldType_DELTA_SUM, Value: 10},
+		},
+	}
+	row := protoToStorageRow(m)
+
+	metricID := metric.ID(1)
+	metaDB.EXPECT().GenMetricID([]byte("ns"), []byte("test1")).Return(metricID, nil)
+	idx.(*indexDatabase).handleRow(row)
+
+	top := idx.QueryTopN(metricID, time.Minute, "f1", 10)
+	assert.Len(t, top, 1)
+	assert.Equal(t, row.GroupByTagsKey(), top[0].SeriesKey)
+
+	ch := make(chan error)
+	idx.Notify(&FlushEvent{Callback: func(err error) { ch <- err }})
+	assert.NoError(t, <-ch)
+	assert.Contains(t, flusher.flushed, metricID)
+}
+
+func TestIndexDatabase_ClearTimeRange_evictsByHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metaDB := index.NewMockMetricMetaDatabase(ctrl)
+	memMetaDB := NewMetadataDatabase(metaDB)
+	indexDB := NewIndexDatabase(memMetaDB, nil)
+
+	m := &protoMetricsV1.Metric{
+		Name:      "test1",
+		Namespace: "ns",
+		Tags:      []*protoMetricsV1.KeyValue{{Key: "key1", Value: "value1"}},
+		SimpleFields: []*protoMetricsV1.SimpleField{
+			{Name: "f1", Type: protoMetricsV1.SimpleFieldType_DELTA_SUM, Value: 10},
+		},
+	}
+	row := protoToStorageRow(m)
+
+	metricID := metric.ID(1)
+	metaDB.EXPECT().GenMetricID([]byte("ns"), []byte("test1")).Return(metricID, nil)
+
+	db := indexDB.(*indexDatabase)
+	db.handleRow(row)
+	assert.Len(t, db.byHash, 1)
+	assert.Len(t, db.byID, 1)
+	assert.Len(t, db.hashByID, 1)
+
+	indexDB.ClearTimeRange(metricID)
+	assert.Empty(t, db.byHash)
+	assert.Empty(t, db.byID)
+	assert.Empty(t, db.hashByID)
+}
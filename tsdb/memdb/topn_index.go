@@ -0,0 +1,337 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package memdb
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lindb/lindb/series/metric"
+)
+
+// defaultTopNWindows are the rolling windows TopNIndex maintains for every
+// metric/measure pair it observes.
+var defaultTopNWindows = []time.Duration{time.Minute, 5 * time.Minute, time.Hour}
+
+// defaultTopNCapacity bounds each Space-Saving sketch to K+epsilon counters.
+const defaultTopNCapacity = 64
+
+// topNBucketsPerWindow is how many sketches a window is split into so it can
+// be rotated incrementally instead of reset all at once.
+const topNBucketsPerWindow = 4
+
+// TopNResult is one ranked entry returned by QueryTopN: seriesKey's
+// estimated measure value, plus the Space-Saving additive error bound.
+type TopNResult struct {
+	SeriesKey string
+	Estimate  float64
+	ErrBound  float64
+}
+
+// TopNFlusher persists a metric's TopN snapshot at flush time, for later
+// use(e.g. merging it with the same metric's snapshots from other shards).
+type TopNFlusher interface {
+	// FlushTopN persists snapshot(window|measure -> ranked series) for metricID.
+	FlushTopN(metricID metric.ID, snapshot map[string][]TopNResult) error
+}
+
+// TopNIndex keeps a Space-Saving sketch per metric/measure/window so
+// QueryTopN can return a ranked series list straight from precomputed
+// state, without scanning the metric's full series set.
+type TopNIndex struct {
+	mutex   sync.RWMutex
+	metrics map[metric.ID]*metricTopN
+}
+
+// NewTopNIndex creates a TopNIndex instance.
+func NewTopNIndex() *TopNIndex {
+	return &TopNIndex{metrics: make(map[metric.ID]*metricTopN)}
+}
+
+// Observe feeds one observed value of measure, grouped by groupByKey(the
+// series' group-by tag values), into metricID's rolling window sketches.
+func (idx *TopNIndex) Observe(metricID metric.ID, groupByKey, measure string, value float64) {
+	idx.mutex.Lock()
+	m, ok := idx.metrics[metricID]
+	if !ok {
+		m = newMetricTopN()
+		idx.metrics[metricID] = m
+	}
+	idx.mutex.Unlock()
+
+	m.observe(measure, groupByKey, value)
+}
+
+// QueryTopN returns the k largest series for metricID/measure/window ranked
+// by their estimated value, descending, along with their error bound.
+func (idx *TopNIndex) QueryTopN(metricID metric.ID, window time.Duration, measure string, k int) []TopNResult {
+	idx.mutex.RLock()
+	m, ok := idx.metrics[metricID]
+	idx.mutex.RUnlock()
+	if !ok {
+		return nil
+	}
+	return m.topN(window, measure, k)
+}
+
+// ClearTimeRange expires every sketch kept for metricID.
+func (idx *TopNIndex) ClearTimeRange(metricID metric.ID) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	delete(idx.metrics, metricID)
+}
+
+// Snapshot returns the current top-K of every window/measure tracked for
+// metricID, keyed by "measure|window", for flushing to a side-file.
+func (idx *TopNIndex) Snapshot(metricID metric.ID) map[string][]TopNResult {
+	idx.mutex.RLock()
+	m, ok := idx.metrics[metricID]
+	idx.mutex.RUnlock()
+	if !ok {
+		return nil
+	}
+	return m.snapshot()
+}
+
+// metricTopN keeps one windowedSketch per measure/window pair observed for a metric.
+type metricTopN struct {
+	mutex    sync.Mutex
+	sketches map[string]*windowedSketch // key: measure
+}
+
+func newMetricTopN() *metricTopN {
+	return &metricTopN{sketches: make(map[string]*windowedSketch)}
+}
+
+func (m *metricTopN) observe(measure, groupByKey string, value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s, ok := m.sketches[measure]
+	if !ok {
+		s = newWindowedSketch(defaultTopNWindows, defaultTopNCapacity)
+		m.sketches[measure] = s
+	}
+	s.observe(groupByKey, value)
+}
+
+func (m *metricTopN) topN(window time.Duration, measure string, k int) []TopNResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s, ok := m.sketches[measure]
+	if !ok {
+		return nil
+	}
+	return s.topN(window, k)
+}
+
+func (m *metricTopN) snapshot() map[string][]TopNResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := make(map[string][]TopNResult)
+	for measure, s := range m.sketches {
+		for _, window := range defaultTopNWindows {
+			top := s.topN(window, defaultTopNCapacity)
+			if len(top) == 0 {
+				continue
+			}
+			result[measure+"|"+window.String()] = top
+		}
+	}
+	return result
+}
+
+// windowedSketch maintains one Space-Saving sketch per rolling window, each
+// split into topNBucketsPerWindow buckets offset by window/N so the window
+// can be rotated incrementally: ticking expires only the oldest slice of
+// data instead of resetting the whole window at once.
+type windowedSketch struct {
+	buckets map[time.Duration][]*topNBucket
+}
+
+type topNBucket struct {
+	sketch *spaceSavingSketch
+	start  time.Time
+}
+
+func newWindowedSketch(windows []time.Duration, capacity int) *windowedSketch {
+	now := time.Now()
+	buckets := make(map[time.Duration][]*topNBucket, len(windows))
+	for _, window := range windows {
+		span := window / topNBucketsPerWindow
+		bs := make([]*topNBucket, topNBucketsPerWindow)
+		for i := range bs {
+			// stagger bucket ages so one is always close to expiring,
+			// keeping rotation smooth instead of resetting in lockstep
+			bs[i] = &topNBucket{
+				sketch: newSpaceSavingSketch(capacity),
+				start:  now.Add(-time.Duration(i) * span),
+			}
+		}
+		buckets[window] = bs
+	}
+	return &windowedSketch{buckets: buckets}
+}
+
+// tick rotates out buckets of window that have aged past it.
+func (w *windowedSketch) tick(window time.Duration, now time.Time) {
+	for _, b := range w.buckets[window] {
+		if now.Sub(b.start) >= window {
+			b.sketch.reset()
+			b.start = now
+		}
+	}
+}
+
+// observe records value for seriesKey in every window's buckets.
+func (w *windowedSketch) observe(seriesKey string, value float64) {
+	now := time.Now()
+	for window, bs := range w.buckets {
+		w.tick(window, now)
+		for _, b := range bs {
+			b.sketch.observe(seriesKey, value)
+		}
+	}
+}
+
+// topN returns the k largest series tracked over window, read from the
+// bucket with the longest continuous coverage of that window.
+func (w *windowedSketch) topN(window time.Duration, k int) []TopNResult {
+	bs, ok := w.buckets[window]
+	if !ok {
+		return nil
+	}
+	w.tick(window, time.Now())
+
+	oldest := bs[0]
+	for _, b := range bs[1:] {
+		if b.start.Before(oldest.start) {
+			oldest = b
+		}
+	}
+	return oldest.sketch.topN(k)
+}
+
+// ssCounter is one Space-Saving counter: an estimated count for key, plus
+// the overcounting error introduced by folding in an evicted counter.
+type ssCounter struct {
+	key      string
+	count    float64
+	errBound float64
+	index    int // position in the owning ssHeap, maintained by heap.Interface
+}
+
+// ssHeap is a min-heap of *ssCounter ordered by count, giving O(1) access to
+// the smallest counter(ssHeap[0]) and O(log n) updates.
+type ssHeap []*ssCounter
+
+func (h ssHeap) Len() int            { return len(h) }
+func (h ssHeap) Less(i, j int) bool  { return h[i].count < h[j].count }
+func (h ssHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ssHeap) Push(x interface{}) {
+	c := x.(*ssCounter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *ssHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	old[n-1] = nil
+	c.index = -1
+	*h = old[:n-1]
+	return c
+}
+
+// spaceSavingSketch implements the Space-Saving streaming top-K algorithm:
+// it bounds memory to `capacity` counters by, once full, evicting the
+// smallest-counter entry and folding its count into the new key's counter,
+// which preserves Space-Saving's additive error bound on every estimate.
+type spaceSavingSketch struct {
+	capacity int
+	byKey    map[string]*ssCounter
+	heap     ssHeap
+}
+
+func newSpaceSavingSketch(capacity int) *spaceSavingSketch {
+	return &spaceSavingSketch{
+		capacity: capacity,
+		byKey:    make(map[string]*ssCounter, capacity),
+		heap:     make(ssHeap, 0, capacity),
+	}
+}
+
+// observe applies the Space-Saving update rule for one occurrence of
+// seriesKey weighted by value.
+func (s *spaceSavingSketch) observe(seriesKey string, value float64) {
+	if c, ok := s.byKey[seriesKey]; ok {
+		c.count += value
+		heap.Fix(&s.heap, c.index)
+		return
+	}
+	if len(s.heap) < s.capacity {
+		c := &ssCounter{key: seriesKey, count: value}
+		s.byKey[seriesKey] = c
+		heap.Push(&s.heap, c)
+		return
+	}
+
+	// full: evict the smallest-counter entry, reuse its slot for the new
+	// key, and set its counter to (evicted counter + observed value) so
+	// the error bound stays tight.
+	min := s.heap[0]
+	delete(s.byKey, min.key)
+	min.key = seriesKey
+	min.errBound = min.count
+	min.count += value
+	s.byKey[seriesKey] = min
+	heap.Fix(&s.heap, min.index)
+}
+
+// reset clears the sketch for reuse by a new window bucket.
+func (s *spaceSavingSketch) reset() {
+	s.byKey = make(map[string]*ssCounter, s.capacity)
+	s.heap = make(ssHeap, 0, s.capacity)
+}
+
+// topN returns the k largest counters, ordered by estimate descending,
+// without mutating the sketch.
+func (s *spaceSavingSketch) topN(k int) []TopNResult {
+	entries := make([]*ssCounter, len(s.heap))
+	copy(entries, s.heap)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].count > entries[j].count })
+
+	if k > len(entries) {
+		k = len(entries)
+	}
+	results := make([]TopNResult, 0, k)
+	for _, c := range entries[:k] {
+		results = append(results, TopNResult{SeriesKey: c.key, Estimate: c.count, ErrBound: c.errBound})
+	}
+	return results
+}
@@ -0,0 +1,79 @@
+// Licensed to LinDB under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. LinDB licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package memdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lindb/lindb/series/metric"
+)
+
+func TestTopNIndex_QueryTopN(t *testing.T) {
+	idx := NewTopNIndex()
+
+	metricID := metric.ID(1)
+	idx.Observe(metricID, "host-1", "cpu", 10)
+	idx.Observe(metricID, "host-2", "cpu", 50)
+	idx.Observe(metricID, "host-3", "cpu", 30)
+
+	top := idx.QueryTopN(metricID, time.Minute, "cpu", 2)
+	assert.Len(t, top, 2)
+	assert.Equal(t, "host-2", top[0].SeriesKey)
+	assert.Equal(t, float64(50), top[0].Estimate)
+	assert.Equal(t, "host-3", top[1].SeriesKey)
+
+	// unknown metric/measure
+	assert.Nil(t, idx.QueryTopN(metric.ID(2), time.Minute, "cpu", 2))
+	assert.Nil(t, idx.QueryTopN(metricID, time.Minute, "memory", 2))
+}
+
+func TestTopNIndex_ClearTimeRange(t *testing.T) {
+	idx := NewTopNIndex()
+	metricID := metric.ID(1)
+	idx.Observe(metricID, "host-1", "cpu", 10)
+
+	idx.ClearTimeRange(metricID)
+	assert.Nil(t, idx.QueryTopN(metricID, time.Minute, "cpu", 1))
+}
+
+func TestTopNIndex_Snapshot(t *testing.T) {
+	idx := NewTopNIndex()
+	metricID := metric.ID(1)
+	idx.Observe(metricID, "host-1", "cpu", 10)
+
+	snapshot := idx.Snapshot(metricID)
+	assert.NotEmpty(t, snapshot)
+	assert.Nil(t, idx.Snapshot(metric.ID(2)))
+}
+
+func TestSpaceSavingSketch_Eviction(t *testing.T) {
+	s := newSpaceSavingSketch(2)
+	s.observe("a", 10)
+	s.observe("b", 5)
+	// full: evicts "b"(smallest), folds its count into "c"
+	s.observe("c", 1)
+
+	top := s.topN(2)
+	assert.Len(t, top, 2)
+	assert.Equal(t, "a", top[0].SeriesKey)
+	assert.Equal(t, "c", top[1].SeriesKey)
+	assert.Equal(t, float64(6), top[1].Estimate) // 5(evicted) + 1(observed)
+}